@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/technoY2K/xkattle/database"
+)
+
+func ledgerCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "ledger",
+		Short: "Interact with the ledger directly",
+	}
+
+	cmd.AddCommand(ledgerCompareCmd())
+
+	return cmd
+}
+
+func ledgerCompareCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "compare <dbA> <dbB>",
+		Short: "Compare two on-disk ledgers and report where (and how) they diverge",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			a, err := database.NewStateFromDisk(database.StoreConfig{DataDir: args[0]})
+			if err != nil {
+				return err
+			}
+			defer a.Close()
+
+			b, err := database.NewStateFromDisk(database.StoreConfig{DataDir: args[1]})
+			if err != nil {
+				return err
+			}
+			defer b.Close()
+
+			diff, err := database.CompareStates(a, b)
+			if err != nil {
+				return err
+			}
+
+			if diff.Match {
+				fmt.Println("Ledgers are identical")
+				return nil
+			}
+
+			fmt.Printf("Ledgers diverge at height %d:\n", diff.DivergentHeight)
+			fmt.Printf("\t%s: %x\n", args[0], diff.DivergentHashA)
+			fmt.Printf("\t%s: %x\n", args[1], diff.DivergentHashB)
+
+			for _, account := range diff.OnlyInA {
+				fmt.Printf("only in %s: %s\n", args[0], account)
+			}
+			for _, account := range diff.OnlyInB {
+				fmt.Printf("only in %s: %s\n", args[1], account)
+			}
+			for account, delta := range diff.BalanceDeltas {
+				fmt.Printf("%s balance delta: %+d\n", account, delta)
+			}
+
+			return nil
+		},
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(ledgerCmd())
+}
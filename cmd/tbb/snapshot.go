@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/technoY2K/xkattle/database"
+)
+
+func snapshotCmd() *cobra.Command {
+	var dataDir string
+
+	cmd := &cobra.Command{
+		Use:   "snapshot",
+		Short: "Interact with the ledger's snapshot",
+	}
+	cmd.PersistentFlags().StringVar(&dataDir, "datadir", "", "Absolute path to the node's data dir")
+
+	cmd.AddCommand(snapshotStatusCmd(&dataDir))
+
+	return cmd
+}
+
+func snapshotStatusCmd(dataDir *string) *cobra.Command {
+	return &cobra.Command{
+		Use:   "status",
+		Short: "Print the current snapshot's hash, height, tx count and size",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			state, err := database.NewStateFromDisk(database.StoreConfig{DataDir: *dataDir})
+			if err != nil {
+				return err
+			}
+			defer state.Close()
+
+			snap := state.SnapshotStatus()
+
+			fmt.Printf("Hash:      %x\n", snap.Hash)
+			fmt.Printf("Height:    %d\n", snap.Height)
+			fmt.Printf("Total TXs: %d\n", snap.TxCount)
+			fmt.Printf("Size:      %s\n", humanizeBytes(snap.SizeBytes))
+
+			return nil
+		},
+	}
+}
+
+func humanizeBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	div, exp := int64(unit), 0
+	for remainder := n / unit; remainder >= unit; remainder /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+func init() {
+	rootCmd.AddCommand(snapshotCmd())
+}
@@ -0,0 +1,100 @@
+package database
+
+// Diff is the result of comparing two ledgers block-by-block: the first
+// height at which they disagree (if any), plus the account-level fallout -
+// balances that moved, and accounts that exist in one ledger but not the
+// other.
+type Diff struct {
+	// Match is true when both ledgers' blocks are identical end-to-end.
+	Match bool
+
+	// DivergentHeight is the first height at which the two ledgers' block
+	// hashes disagree, or one ledger ran out of blocks before the other.
+	// Zero when Match is true.
+	DivergentHeight uint64
+	DivergentHashA  Hash
+	DivergentHashB  Hash
+
+	// OnlyInA/OnlyInB are accounts present in one ledger's balances but
+	// absent from the other's.
+	OnlyInA []Account
+	OnlyInB []Account
+
+	// BalanceDeltas holds, for every account present in both ledgers with a
+	// different balance, b's balance minus a's.
+	BalanceDeltas map[Account]int64
+}
+
+// CompareStates walks a and b's stores block-by-block from genesis forward,
+// stopping at the first height whose block hash disagrees (or where one
+// ledger runs out of blocks first), then reports every balance that differs
+// between the two resulting account states.
+func CompareStates(a, b *State) (*Diff, error) {
+	itA, err := a.store.Iterate(Hash{})
+	if err != nil {
+		return nil, err
+	}
+	itB, err := b.store.Iterate(Hash{})
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &Diff{Match: true, BalanceDeltas: make(map[Account]int64)}
+
+	var height uint64
+	for {
+		hasA := itA.Next()
+		hasB := itB.Next()
+
+		if !hasA && !hasB {
+			break
+		}
+
+		var hashA, hashB Hash
+		if hasA {
+			if hashA, err = itA.Block().Hash(); err != nil {
+				return nil, err
+			}
+		}
+		if hasB {
+			if hashB, err = itB.Block().Hash(); err != nil {
+				return nil, err
+			}
+		}
+
+		if !hasA || !hasB || hashA != hashB {
+			diff.Match = false
+			diff.DivergentHeight = height
+			diff.DivergentHashA = hashA
+			diff.DivergentHashB = hashB
+			break
+		}
+
+		height++
+	}
+
+	if err := itA.Err(); err != nil {
+		return nil, err
+	}
+	if err := itB.Err(); err != nil {
+		return nil, err
+	}
+
+	for account, balance := range a.Balances {
+		other, ok := b.Balances[account]
+		if !ok {
+			diff.OnlyInA = append(diff.OnlyInA, account)
+			continue
+		}
+		if other != balance {
+			diff.BalanceDeltas[account] = int64(other) - int64(balance)
+		}
+	}
+	for account := range b.Balances {
+		if _, ok := a.Balances[account]; !ok {
+			diff.OnlyInB = append(diff.OnlyInB, account)
+		}
+	}
+
+	return diff, nil
+}
@@ -0,0 +1,72 @@
+package database
+
+import "testing"
+
+func TestCompareStatesMatch(t *testing.T) {
+	a := newTestState(t, map[Account]uint{"alice": 1000})
+	b := newTestState(t, map[Account]uint{"alice": 1000})
+
+	block := Block{Header: BlockHeader{Parent: Hash{}}, TXs: []Tx{{From: "alice", To: "bob", Value: 100}}}
+	if _, err := a.store.PutBlock(block); err != nil {
+		t.Fatalf("a.store.PutBlock: %v", err)
+	}
+	if _, err := b.store.PutBlock(block); err != nil {
+		t.Fatalf("b.store.PutBlock: %v", err)
+	}
+	a.Balances = map[Account]uint{"alice": 900, "bob": 100}
+	b.Balances = map[Account]uint{"alice": 900, "bob": 100}
+
+	diff, err := CompareStates(a, b)
+	if err != nil {
+		t.Fatalf("CompareStates: %v", err)
+	}
+	if !diff.Match {
+		t.Fatalf("expected matching ledgers, got %+v", diff)
+	}
+}
+
+func TestCompareStatesReportsDivergence(t *testing.T) {
+	a := newTestState(t, map[Account]uint{"alice": 1000})
+	b := newTestState(t, map[Account]uint{"alice": 1000})
+
+	blockA := Block{Header: BlockHeader{Parent: Hash{}}, TXs: []Tx{{From: "alice", To: "bob", Value: 100}}}
+	blockB := Block{Header: BlockHeader{Parent: Hash{}}, TXs: []Tx{{From: "alice", To: "carl", Value: 50}}}
+
+	if _, err := a.store.PutBlock(blockA); err != nil {
+		t.Fatalf("a.store.PutBlock: %v", err)
+	}
+	if _, err := b.store.PutBlock(blockB); err != nil {
+		t.Fatalf("b.store.PutBlock: %v", err)
+	}
+	a.Balances = map[Account]uint{"alice": 900, "bob": 100}
+	b.Balances = map[Account]uint{"alice": 950, "carl": 50}
+
+	diff, err := CompareStates(a, b)
+	if err != nil {
+		t.Fatalf("CompareStates: %v", err)
+	}
+	if diff.Match {
+		t.Fatal("expected divergent ledgers, got a match")
+	}
+	if diff.DivergentHeight != 0 {
+		t.Fatalf("DivergentHeight = %d, want 0", diff.DivergentHeight)
+	}
+
+	onlyA := map[Account]bool{}
+	for _, acc := range diff.OnlyInA {
+		onlyA[acc] = true
+	}
+	onlyB := map[Account]bool{}
+	for _, acc := range diff.OnlyInB {
+		onlyB[acc] = true
+	}
+	if !onlyA["bob"] {
+		t.Fatalf("expected bob only in a, got %+v", diff)
+	}
+	if !onlyB["carl"] {
+		t.Fatalf("expected carl only in b, got %+v", diff)
+	}
+	if diff.BalanceDeltas["alice"] != 50 {
+		t.Fatalf("alice balance delta = %d, want 50", diff.BalanceDeltas["alice"])
+	}
+}
@@ -0,0 +1,72 @@
+package database
+
+import "fmt"
+
+// fakeStore is an in-memory Store for tests that don't want to touch the
+// filesystem or a real LevelDB instance. Set failPut to make PutBlock error,
+// for exercising Persist/Close failure paths.
+type fakeStore struct {
+	blocks  []Block
+	hashes  []Hash
+	failPut bool
+}
+
+func (s *fakeStore) GetBlock(hash Hash) (Block, error) {
+	for i, h := range s.hashes {
+		if h == hash {
+			return s.blocks[i], nil
+		}
+	}
+	return Block{}, fmt.Errorf("block %x not found", hash)
+}
+
+func (s *fakeStore) PutBlock(block Block) (Hash, error) {
+	if s.failPut {
+		return Hash{}, fmt.Errorf("fake store: put failed")
+	}
+
+	hash, err := block.Hash()
+	if err != nil {
+		return Hash{}, err
+	}
+
+	s.blocks = append(s.blocks, block)
+	s.hashes = append(s.hashes, hash)
+	return hash, nil
+}
+
+func (s *fakeStore) LatestHash() (Hash, bool) {
+	if len(s.hashes) == 0 {
+		return Hash{}, false
+	}
+	return s.hashes[len(s.hashes)-1], true
+}
+
+func (s *fakeStore) Iterate(from Hash) (StoreIterator, error) {
+	start := 0
+	if from != (Hash{}) {
+		for i, h := range s.hashes {
+			if h == from {
+				start = i + 1
+				break
+			}
+		}
+	}
+	return &fakeStoreIterator{blocks: s.blocks[start:], idx: -1}, nil
+}
+
+func (s *fakeStore) Close() error { return nil }
+
+type fakeStoreIterator struct {
+	blocks []Block
+	idx    int
+}
+
+func (it *fakeStoreIterator) Next() bool {
+	it.idx++
+	return it.idx < len(it.blocks)
+}
+
+func (it *fakeStoreIterator) Block() Block { return it.blocks[it.idx] }
+
+func (it *fakeStoreIterator) Err() error { return nil }
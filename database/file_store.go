@@ -0,0 +1,148 @@
+package database
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// fileStore is the historic Store implementation: blocks are appended to
+// block.db as newline-delimited {Key, Value} JSON records and looked up by
+// scanning the file from the top. It trades startup replay time for having
+// no external dependency.
+type fileStore struct {
+	dbFile     *os.File
+	latestHash Hash
+	hasLatest  bool
+}
+
+func newFileStore(dataDir string) (*fileStore, error) {
+	f, err := os.OpenFile(filepath.Join(dataDir, "block.db"), os.O_APPEND|os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	fs := &fileStore{dbFile: f}
+
+	it, err := fs.Iterate(Hash{})
+	if err != nil {
+		return nil, err
+	}
+	for it.Next() {
+		block := it.Block()
+		hash, err := block.Hash()
+		if err != nil {
+			return nil, err
+		}
+		fs.latestHash = hash
+		fs.hasLatest = true
+	}
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+
+	return fs, nil
+}
+
+func (s *fileStore) GetBlock(hash Hash) (Block, error) {
+	it, err := s.Iterate(Hash{})
+	if err != nil {
+		return Block{}, err
+	}
+
+	for it.Next() {
+		block := it.Block()
+		blockHash, err := block.Hash()
+		if err != nil {
+			return Block{}, err
+		}
+		if blockHash == hash {
+			return block, nil
+		}
+	}
+
+	return Block{}, it.Err()
+}
+
+func (s *fileStore) PutBlock(block Block) (Hash, error) {
+	hash, err := block.Hash()
+	if err != nil {
+		return Hash{}, err
+	}
+
+	blockJSON, err := json.Marshal(dbFS{Key: hash, Value: block})
+	if err != nil {
+		return Hash{}, err
+	}
+
+	if _, err := s.dbFile.Write(append(blockJSON, '\n')); err != nil {
+		return Hash{}, err
+	}
+
+	s.latestHash = hash
+	s.hasLatest = true
+
+	return hash, nil
+}
+
+func (s *fileStore) LatestHash() (Hash, bool) {
+	return s.latestHash, s.hasLatest
+}
+
+func (s *fileStore) Iterate(from Hash) (StoreIterator, error) {
+	if _, err := s.dbFile.Seek(0, 0); err != nil {
+		return nil, err
+	}
+
+	return &fileStoreIterator{scanner: bufio.NewScanner(s.dbFile), from: from, skip: from != (Hash{})}, nil
+}
+
+func (s *fileStore) Close() error {
+	return s.dbFile.Close()
+}
+
+// fileStoreIterator yields every block in block.db in append order, skipping
+// everything up to and including `from` when it isn't the zero hash.
+type fileStoreIterator struct {
+	scanner *bufio.Scanner
+	from    Hash
+	skip    bool
+	block   Block
+	err     error
+}
+
+func (it *fileStoreIterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+
+	for it.scanner.Scan() {
+		var rec dbFS
+		if err := json.Unmarshal(it.scanner.Bytes(), &rec); err != nil {
+			it.err = err
+			return false
+		}
+
+		if it.skip {
+			if rec.Key == it.from {
+				it.skip = false
+			}
+			continue
+		}
+
+		it.block = rec.Value
+		return true
+	}
+
+	it.err = it.scanner.Err()
+	return false
+}
+
+func (it *fileStoreIterator) Block() Block {
+	return it.block
+}
+
+func (it *fileStoreIterator) Err() error {
+	return it.err
+}
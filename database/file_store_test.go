@@ -0,0 +1,59 @@
+package database
+
+import "testing"
+
+func TestFileStoreIteratesOldestFirst(t *testing.T) {
+	dir := t.TempDir()
+
+	store, err := newFileStore(dir)
+	if err != nil {
+		t.Fatalf("newFileStore: %v", err)
+	}
+	defer store.Close()
+
+	var parent Hash
+	var hashes []Hash
+	for i := 0; i < 3; i++ {
+		block := Block{Header: BlockHeader{Parent: parent}, TXs: []Tx{{To: Account("bob"), Value: uint(i)}}}
+		hash, err := store.PutBlock(block)
+		if err != nil {
+			t.Fatalf("PutBlock %d: %v", i, err)
+		}
+		hashes = append(hashes, hash)
+		parent = hash
+	}
+
+	it, err := store.Iterate(Hash{})
+	if err != nil {
+		t.Fatalf("Iterate: %v", err)
+	}
+
+	var got []Hash
+	for it.Next() {
+		hash, err := it.Block().Hash()
+		if err != nil {
+			t.Fatalf("Block.Hash: %v", err)
+		}
+		got = append(got, hash)
+	}
+	if err := it.Err(); err != nil {
+		t.Fatalf("iterator error: %v", err)
+	}
+
+	if len(got) != len(hashes) {
+		t.Fatalf("got %d blocks, want %d", len(got), len(hashes))
+	}
+	for i := range hashes {
+		if got[i] != hashes[i] {
+			t.Fatalf("block %d out of order: got %x, want %x", i, got[i], hashes[i])
+		}
+	}
+
+	latest, ok := store.LatestHash()
+	if !ok {
+		t.Fatal("LatestHash: expected ok=true")
+	}
+	if latest != hashes[len(hashes)-1] {
+		t.Fatalf("LatestHash = %x, want %x (the real tip)", latest, hashes[len(hashes)-1])
+	}
+}
@@ -0,0 +1,188 @@
+package database
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/iterator"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// Blocks are keyed by hash under blockKeyPrefix, with a height -> hash index
+// under heightKeyPrefix and a single latestBlockStoreState record for the
+// current head.
+const (
+	blockKeyPrefix  = "B:"
+	heightKeyPrefix = "H:"
+	latestStateKey  = "latestBlockStoreState"
+)
+
+// latestBlockStoreState is the metadata record levelDBStore keeps under
+// latestStateKey so LatestHash doesn't need a table scan.
+type latestBlockStoreState struct {
+	Hash   Hash
+	Height uint64
+}
+
+// levelDBStore backs Store with LevelDB, so startup replay walks the height
+// index and GetBlock is a keyed read by hash instead of a scan over block.db.
+// The height index is read only by Iterate; there is no lookup by height.
+type levelDBStore struct {
+	db *leveldb.DB
+}
+
+func newLevelDBStore(dataDir string) (*levelDBStore, error) {
+	db, err := leveldb.OpenFile(dataDir, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &levelDBStore{db: db}, nil
+}
+
+func blockKey(hash Hash) []byte {
+	return []byte(fmt.Sprintf("%s%x", blockKeyPrefix, hash))
+}
+
+func heightKey(height uint64) []byte {
+	return []byte(fmt.Sprintf("%s%020d", heightKeyPrefix, height))
+}
+
+// parseHexHash decodes the hex-encoded hash stored as a height index value.
+func parseHexHash(data []byte) (Hash, error) {
+	var hash Hash
+	decoded, err := hex.DecodeString(string(data))
+	if err != nil {
+		return Hash{}, err
+	}
+	copy(hash[:], decoded)
+	return hash, nil
+}
+
+func (s *levelDBStore) GetBlock(hash Hash) (Block, error) {
+	data, err := s.db.Get(blockKey(hash), nil)
+	if err != nil {
+		return Block{}, err
+	}
+
+	var block Block
+	if err := json.Unmarshal(data, &block); err != nil {
+		return Block{}, err
+	}
+
+	return block, nil
+}
+
+func (s *levelDBStore) PutBlock(block Block) (Hash, error) {
+	hash, err := block.Hash()
+	if err != nil {
+		return Hash{}, err
+	}
+
+	blockData, err := json.Marshal(block)
+	if err != nil {
+		return Hash{}, err
+	}
+
+	batch := new(leveldb.Batch)
+	batch.Put(blockKey(hash), blockData)
+	batch.Put(heightKey(block.Header.Number), []byte(fmt.Sprintf("%x", hash)))
+
+	state := latestBlockStoreState{Hash: hash, Height: block.Header.Number}
+	stateData, err := json.Marshal(state)
+	if err != nil {
+		return Hash{}, err
+	}
+	batch.Put([]byte(latestStateKey), stateData)
+
+	if err := s.db.Write(batch, nil); err != nil {
+		return Hash{}, err
+	}
+
+	return hash, nil
+}
+
+func (s *levelDBStore) LatestHash() (Hash, bool) {
+	data, err := s.db.Get([]byte(latestStateKey), nil)
+	if err != nil {
+		return Hash{}, false
+	}
+
+	var state latestBlockStoreState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return Hash{}, false
+	}
+
+	return state.Hash, true
+}
+
+// Iterate walks blocks oldest-first by height, using the height -> hash
+// index rather than scanning the "B:" keys directly - a scan over blockKey
+// would come back in lexicographic hash order, not chain order, since
+// LevelDB iterates keys byte-wise rather than by insertion order.
+func (s *levelDBStore) Iterate(from Hash) (StoreIterator, error) {
+	iter := s.db.NewIterator(util.BytesPrefix([]byte(heightKeyPrefix)), nil)
+	return &levelDBStoreIterator{db: s.db, iter: iter, from: from, skip: from != (Hash{})}, nil
+}
+
+func (s *levelDBStore) Close() error {
+	return s.db.Close()
+}
+
+type levelDBStoreIterator struct {
+	db    *leveldb.DB
+	iter  iterator.Iterator
+	from  Hash
+	skip  bool
+	block Block
+	err   error
+}
+
+func (it *levelDBStoreIterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+
+	for it.iter.Next() {
+		hash, err := parseHexHash(it.iter.Value())
+		if err != nil {
+			it.err = err
+			return false
+		}
+
+		if it.skip {
+			if hash == it.from {
+				it.skip = false
+			}
+			continue
+		}
+
+		data, err := it.db.Get(blockKey(hash), nil)
+		if err != nil {
+			it.err = err
+			return false
+		}
+
+		var block Block
+		if err := json.Unmarshal(data, &block); err != nil {
+			it.err = err
+			return false
+		}
+
+		it.block = block
+		return true
+	}
+
+	it.err = it.iter.Error()
+	return false
+}
+
+func (it *levelDBStoreIterator) Block() Block {
+	return it.block
+}
+
+func (it *levelDBStoreIterator) Err() error {
+	return it.err
+}
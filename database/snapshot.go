@@ -0,0 +1,15 @@
+package database
+
+// Snapshot is a cheap summary of the ledger's current state, kept up to date
+// by doSnapshot rather than re-hashed from block.db on every call.
+type Snapshot struct {
+	Hash      Hash
+	Height    uint64
+	TxCount   uint64
+	SizeBytes int64
+}
+
+// SnapshotStatus returns the ledger's current Snapshot.
+func (s *State) SnapshotStatus() Snapshot {
+	return s.snapshot
+}
@@ -0,0 +1,99 @@
+package database
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const journalFileName = "snapshot.journal"
+
+// journalEntry is the on-disk form of a diffLayer: enough to rebuild its
+// balance diffs without re-applying its txs against the tree. The UTXO keys
+// the layer spent and created are not journalled, so a rebuilt layer carries
+// none and replaying it leaves the UTXO view unchanged.
+type journalEntry struct {
+	ParentRoot    Hash
+	NewRoot       Hash
+	BalanceDeltas map[Account]uint
+	Txs           []Tx
+}
+
+// openJournal opens (creating if necessary) the journal file diff-layers are
+// appended to as State.Add pushes them, so they survive a crash before
+// Persist has flattened them into the disk layer.
+func openJournal(dataDir string) (*os.File, error) {
+	return os.OpenFile(filepath.Join(dataDir, journalFileName), os.O_APPEND|os.O_CREATE|os.O_RDWR, 0600)
+}
+
+// readJournal loads every entry currently in the journal, in the order they
+// were appended.
+func readJournal(f *os.File) ([]journalEntry, error) {
+	if _, err := f.Seek(0, 0); err != nil {
+		return nil, err
+	}
+
+	var entries []journalEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry journalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, scanner.Err()
+}
+
+// appendJournalEntry records a freshly pushed diffLayer.
+func appendJournalEntry(f *os.File, entry journalEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+// rewriteJournal replaces the journal's contents with entries, used after
+// Persist flattens the bottom-most diff-layer so the journal only ever holds
+// layers that haven't reached the disk layer yet.
+func rewriteJournal(f *os.File, entries []journalEntry) error {
+	if err := f.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := f.Seek(0, 0); err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if err := appendJournalEntry(f, entry); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// truncateJournal empties the journal, used on a clean Close once there's
+// nothing left worth replaying.
+func truncateJournal(f *os.File) error {
+	return rewriteJournal(f, nil)
+}
+
+// verifyJournal checks that entries chain cleanly from root, i.e. each
+// entry's ParentRoot matches the root the previous entry produced.
+func verifyJournal(root Hash, entries []journalEntry) error {
+	top := root
+	for i, entry := range entries {
+		if entry.ParentRoot != top {
+			return fmt.Errorf("journal entry %d: parent root %x does not match current top %x", i, entry.ParentRoot, top)
+		}
+		top = entry.NewRoot
+	}
+	return nil
+}
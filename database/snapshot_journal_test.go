@@ -0,0 +1,78 @@
+package database
+
+import "testing"
+
+func TestJournalAppendReadRewriteRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+
+	f, err := openJournal(dir)
+	if err != nil {
+		t.Fatalf("openJournal: %v", err)
+	}
+	defer f.Close()
+
+	entries := []journalEntry{
+		{ParentRoot: Hash{}, NewRoot: Hash{1}, BalanceDeltas: map[Account]uint{"alice": 90}},
+		{ParentRoot: Hash{1}, NewRoot: Hash{2}, BalanceDeltas: map[Account]uint{"bob": 10}},
+	}
+	for _, e := range entries {
+		if err := appendJournalEntry(f, e); err != nil {
+			t.Fatalf("appendJournalEntry: %v", err)
+		}
+	}
+
+	got, err := readJournal(f)
+	if err != nil {
+		t.Fatalf("readJournal: %v", err)
+	}
+	if len(got) != len(entries) {
+		t.Fatalf("got %d entries, want %d", len(got), len(entries))
+	}
+	for i := range entries {
+		if got[i].ParentRoot != entries[i].ParentRoot || got[i].NewRoot != entries[i].NewRoot {
+			t.Fatalf("entry %d = %+v, want %+v", i, got[i], entries[i])
+		}
+	}
+
+	if err := rewriteJournal(f, got[1:]); err != nil {
+		t.Fatalf("rewriteJournal: %v", err)
+	}
+	got, err = readJournal(f)
+	if err != nil {
+		t.Fatalf("readJournal after rewrite: %v", err)
+	}
+	if len(got) != 1 || got[0].NewRoot != (Hash{2}) {
+		t.Fatalf("after rewrite, got %+v, want single entry with NewRoot=%x", got, Hash{2})
+	}
+
+	if err := truncateJournal(f); err != nil {
+		t.Fatalf("truncateJournal: %v", err)
+	}
+	got, err = readJournal(f)
+	if err != nil {
+		t.Fatalf("readJournal after truncate: %v", err)
+	}
+	if len(got) != 0 {
+		t.Fatalf("after truncate, got %+v, want none", got)
+	}
+}
+
+func TestVerifyJournalRejectsBrokenChain(t *testing.T) {
+	root := Hash{}
+
+	ok := []journalEntry{
+		{ParentRoot: root, NewRoot: Hash{1}},
+		{ParentRoot: Hash{1}, NewRoot: Hash{2}},
+	}
+	if err := verifyJournal(root, ok); err != nil {
+		t.Fatalf("expected a clean chain to verify, got: %v", err)
+	}
+
+	broken := []journalEntry{
+		{ParentRoot: root, NewRoot: Hash{1}},
+		{ParentRoot: Hash{99}, NewRoot: Hash{2}},
+	}
+	if err := verifyJournal(root, broken); err == nil {
+		t.Fatal("expected a broken chain to fail verification")
+	}
+}
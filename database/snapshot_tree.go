@@ -0,0 +1,137 @@
+package database
+
+import "fmt"
+
+// diskLayer is the bottom of the snapshotTree: the balances recovered by
+// replaying block.db from genesis, together with the root hash they were
+// snapshotted under.
+type diskLayer struct {
+	root     Hash
+	balances map[Account]uint
+}
+
+// diffLayer holds the balance mutations produced by applying a single Tx on
+// top of its parent layer, plus the resulting root hash. Stacking diffLayers
+// lets State.Add record new state without touching the disk layer, and lets
+// State.Revert cheaply drop bad layers again. utxoSpent/utxoCreated record
+// the UTXO-side effects of the same txs, so Revert can undo them too.
+type diffLayer struct {
+	parent Hash
+	root   Hash
+	diffs  map[Account]uint
+	txs    []Tx
+
+	utxoSpent   []utxoKey
+	utxoCreated []utxoKey
+}
+
+// snapshotTree is a diskLayer with zero or more diffLayers stacked on top of
+// it, oldest first. The top of the stack is what reads see; Persist flattens
+// the bottom-most diffLayer into the disk layer as its txs become durable.
+type snapshotTree struct {
+	disk  *diskLayer
+	diffs []*diffLayer
+}
+
+func newSnapshotTree(root Hash, balances map[Account]uint) *snapshotTree {
+	return &snapshotTree{disk: &diskLayer{root: root, balances: balances}}
+}
+
+// top returns the root hash of the uppermost layer.
+func (t *snapshotTree) top() Hash {
+	if len(t.diffs) == 0 {
+		return t.disk.root
+	}
+	return t.diffs[len(t.diffs)-1].root
+}
+
+// balance walks the stack from the newest diffLayer down to the disk layer,
+// returning the first value it finds for account.
+func (t *snapshotTree) balance(account Account) uint {
+	for i := len(t.diffs) - 1; i >= 0; i-- {
+		if v, ok := t.diffs[i].diffs[account]; ok {
+			return v
+		}
+	}
+	return t.disk.balances[account]
+}
+
+// balances materializes the full account -> balance view at the top of the
+// tree, for callers that still want the flat map the old State.Balances gave
+// them (e.g. `balances list`).
+func (t *snapshotTree) balances() map[Account]uint {
+	out := make(map[Account]uint, len(t.disk.balances))
+	for account, balance := range t.disk.balances {
+		out[account] = balance
+	}
+	for _, d := range t.diffs {
+		for account, balance := range d.diffs {
+			out[account] = balance
+		}
+	}
+	return out
+}
+
+// push adds a new diffLayer on top of the tree.
+func (t *snapshotTree) push(parent Hash, diffs map[Account]uint, txs []Tx, root Hash, utxoSpent, utxoCreated []utxoKey) *diffLayer {
+	d := &diffLayer{parent: parent, root: root, diffs: diffs, txs: txs, utxoSpent: utxoSpent, utxoCreated: utxoCreated}
+	t.diffs = append(t.diffs, d)
+	return d
+}
+
+// revert discards every diffLayer above root, making root the new top of the
+// tree, and returns the discarded layers newest-first so the caller can undo
+// any side effects (like UTXO mutations) they carried. root must be the disk
+// layer's root or the root of one of the stacked diffLayers, otherwise an
+// error is returned and the tree is left untouched.
+func (t *snapshotTree) revert(root Hash) ([]*diffLayer, error) {
+	if root == t.disk.root {
+		discarded := t.diffs
+		t.diffs = nil
+		return reverseDiffLayers(discarded), nil
+	}
+
+	for i, d := range t.diffs {
+		if d.root == root {
+			discarded := t.diffs[i+1:]
+			t.diffs = t.diffs[:i+1]
+			return reverseDiffLayers(discarded), nil
+		}
+	}
+
+	return nil, fmt.Errorf("unknown snapshot root %x", root)
+}
+
+func reverseDiffLayers(in []*diffLayer) []*diffLayer {
+	out := make([]*diffLayer, len(in))
+	for i, d := range in {
+		out[len(in)-1-i] = d
+	}
+	return out
+}
+
+// journalEntries returns the stacked diffLayers in the form the snapshot
+// journal persists them in.
+func (t *snapshotTree) journalEntries() []journalEntry {
+	entries := make([]journalEntry, len(t.diffs))
+	for i, d := range t.diffs {
+		entries[i] = journalEntry{ParentRoot: d.parent, NewRoot: d.root, BalanceDeltas: d.diffs, Txs: d.txs}
+	}
+	return entries
+}
+
+// flattenBottom merges the oldest diffLayer into the disk layer and pops it
+// off the stack. Persist calls this once a diffLayer's txs have been written
+// to block.db, since at that point the disk layer can safely absorb it.
+func (t *snapshotTree) flattenBottom() {
+	if len(t.diffs) == 0 {
+		return
+	}
+
+	bottom := t.diffs[0]
+	for account, balance := range bottom.diffs {
+		t.disk.balances[account] = balance
+	}
+	t.disk.root = bottom.root
+	t.diffs = t.diffs[1:]
+}
@@ -1,11 +1,9 @@
 package database
 
 import (
-	"bufio"
 	"crypto/sha256"
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"os"
 	"path/filepath"
 )
@@ -15,18 +13,31 @@ type State struct {
 	Balances  map[Account]uint
 	txMempool []Tx
 
-	dbFile          *os.File
+	store           Store
 	latestBlockHash Hash
+
+	snapshot Snapshot
+	tree     *snapshotTree
+	utxo     *UTXOView
+	journal  *os.File
+
+	dataDir string
 }
 
-// NewStateFromDisk starts the ledger from the genesis
-func NewStateFromDisk() (*State, error) {
-	cwd, err := os.Getwd()
-	if err != nil {
-		return nil, err
+// NewStateFromDisk starts the ledger from the genesis and replays whatever
+// blocks cfg's Store already knows about. Passing the zero StoreConfig keeps
+// the historic append-only block.db behavior.
+func NewStateFromDisk(cfg StoreConfig) (*State, error) {
+	dataDir := cfg.DataDir
+	if dataDir == "" {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return nil, err
+		}
+		dataDir = filepath.Join(cwd, "database")
 	}
 
-	gen, err := loadGenesis(filepath.Join(cwd, "database", "genesis.json"))
+	gen, err := loadGenesis(filepath.Join(dataDir, "genesis.json"))
 	if err != nil {
 		return nil, err
 	}
@@ -36,34 +47,63 @@ func NewStateFromDisk() (*State, error) {
 		balances[account] = balance
 	}
 
-	f, err := os.OpenFile(filepath.Join(cwd, "database", "block.db"), os.O_APPEND|os.O_RDWR, 0600)
+	var store Store
+	if cfg.UseLevelDB {
+		store, err = newLevelDBStore(dataDir)
+	} else {
+		store, err = newFileStore(dataDir)
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	scanner := bufio.NewScanner(f)
+	journal, err := openJournal(dataDir)
+	if err != nil {
+		return nil, err
+	}
 
-	state := &State{balances, make([]Tx, 0), f, Hash{}}
+	tree := newSnapshotTree(Hash{}, balances)
+	utxo := newUTXOView()
+	seedGenesisUTXO(utxo, balances)
+	state := &State{balances, make([]Tx, 0), store, Hash{}, Snapshot{}, tree, utxo, journal, dataDir}
 
-	for scanner.Scan() {
-		if err := scanner.Err(); err != nil {
-			return nil, err
-		}
+	it, err := store.Iterate(Hash{})
+	if err != nil {
+		return nil, err
+	}
+	for it.Next() {
+		block := it.Block()
 
-		dbFSJSON := scanner.Bytes()
-		var dbFS dbFS
-		err = json.Unmarshal(dbFSJSON, &dbFS)
-		if err != nil {
+		if err := state.applyBlock(block); err != nil {
 			return nil, err
 		}
 
-		err = state.applyBlock(dbFS.Value)
+		hash, err := block.Hash()
 		if err != nil {
 			return nil, err
 		}
+		state.latestBlockHash = hash
+	}
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+	tree.disk.root = state.latestBlockHash
 
-		state.latestBlockHash = dbFS.Key
+	entries, err := readJournal(journal)
+	if err != nil {
+		return nil, err
 	}
+	if cfg.JournalCheck {
+		if err := verifyJournal(tree.top(), entries); err != nil {
+			fmt.Printf("discarding snapshot journal: %s\n", err)
+			entries = nil
+			if err := truncateJournal(journal); err != nil {
+				return nil, err
+			}
+		}
+	}
+	replayJournalEntries(state, tree, entries)
+	state.Balances = tree.balances()
 
 	err = state.doSnapshot()
 	if err != nil {
@@ -73,80 +113,193 @@ func NewStateFromDisk() (*State, error) {
 	return state, nil
 }
 
-// LatestSnapshot returns the current hash of the tx.db file
+// replayJournalEntries pushes recovered journal entries back onto tree and
+// repopulates state.txMempool with the txs they carried, so a tx that was
+// never flushed before a crash can still be Persisted/flattened exactly as
+// it would've been had the crash not happened.
+func replayJournalEntries(state *State, tree *snapshotTree, entries []journalEntry) {
+	for _, entry := range entries {
+		tree.push(entry.ParentRoot, entry.BalanceDeltas, entry.Txs, entry.NewRoot, nil, nil)
+		state.txMempool = append(state.txMempool, entry.Txs...)
+	}
+}
+
+// LatestSnapshot returns the ledger's current Snapshot
 func (s *State) LatestSnapshot() Snapshot {
 	return s.snapshot
 }
 
-// Add a tx to the state mempool
+// Add a tx to the state mempool. Rather than mutating Balances in place, the
+// resulting balances are pushed as a new diffLayer on top of the snapshot
+// tree, so a bad tx (or a reorg'd block) can be undone with Revert instead of
+// reloading the whole ledger from disk.
 func (s *State) Add(tx Tx) error {
-	if err := s.apply(tx); err != nil {
+	diffs, utxoSpent, utxoCreated, err := s.apply(tx)
+	if err != nil {
+		return err
+	}
+
+	parent := s.tree.top()
+	root := s.computeRoot(parent, diffs, []Tx{tx})
+	s.tree.push(parent, diffs, []Tx{tx}, root, utxoSpent, utxoCreated)
+	s.Balances = s.tree.balances()
+
+	if err := appendJournalEntry(s.journal, journalEntry{ParentRoot: parent, NewRoot: root, BalanceDeltas: diffs, Txs: []Tx{tx}}); err != nil {
 		return err
 	}
+
 	s.txMempool = append(s.txMempool, tx)
 	return nil
 }
 
-// Persist mempool to disk
+// Revert discards every diff-layer above root, undoes the UTXO mutations
+// those layers carried, and trims the matching tail of txMempool so it
+// stays one-to-one with s.tree.diffs - letting callers cheaply undo bad txs
+// or reorg blocks without reloading from disk.
+func (s *State) Revert(root Hash) error {
+	discarded, err := s.tree.revert(root)
+	if err != nil {
+		return err
+	}
+
+	for _, d := range discarded {
+		s.utxo.undo(d.utxoSpent, d.utxoCreated)
+	}
+
+	s.Balances = s.tree.balances()
+	s.txMempool = s.txMempool[:len(s.tree.diffs)]
+	return nil
+}
+
+// Persist mempool to disk, oldest tx first - the same order they were
+// pushed as diff-layers in, so each iteration's flattenBottom() always
+// corresponds to the tx just written.
 func (s *State) Persist() (Snapshot, error) {
 	mempool := make([]Tx, len(s.txMempool))
 	copy(mempool, s.txMempool)
 
-	for i := 0; i < len(mempool); i++ {
-		txJSON, err := json.Marshal(s.txMempool[i])
+	for _, tx := range mempool {
+		fmt.Printf("Persisting new TX to disk:\n")
+		fmt.Printf("\t%+v\n", tx)
+
+		block := Block{Header: BlockHeader{Parent: s.latestBlockHash, Number: s.snapshot.Height}, TXs: []Tx{tx}}
+		hash, err := s.store.PutBlock(block)
 		if err != nil {
 			return Snapshot{}, err
 		}
+		s.latestBlockHash = hash
 
-		fmt.Printf("Persisting new TX to disk:\n")
-		fmt.Printf("\t%s\n", txJSON)
-		if _, err = s.dbFile.Write(append(txJSON, '\n')); err != nil {
+		err = s.doSnapshot()
+		if err != nil {
 			return Snapshot{}, err
 		}
+		fmt.Printf("New DB Snapshot: %x\n", s.snapshot.Hash)
 
-		err = s.doSnapshot()
-		if err != nil {
+		// The tx this diff-layer came from is now durable, so its layer can
+		// be flattened into the disk layer instead of staying stacked, and
+		// dropped from the journal along with it.
+		s.tree.flattenBottom()
+		if err := rewriteJournal(s.journal, s.tree.journalEntries()); err != nil {
 			return Snapshot{}, err
 		}
-		fmt.Printf("New DB Snapshot: %x\n", s.snapshot)
 
-		s.txMempool = append(s.txMempool[:i], s.txMempool[i+1:]...)
+		if err := s.utxo.saveToFile(filepath.Join(s.dataDir, "utxo.json")); err != nil {
+			return Snapshot{}, err
+		}
+
+		s.txMempool = s.txMempool[1:]
 	}
 	return s.snapshot, nil
 }
 
-// Close references to the file
+// Close flushes any still-pending mempool txs to disk and, only once every
+// diff-layer has been flattened into the disk layer, truncates the snapshot
+// journal. If Persist fails the journal is left alone, so a crash (or a
+// second failed Close) can still recover the unflushed txs from it.
 func (s *State) Close() {
-	s.dbFile.Close()
+	if _, err := s.Persist(); err != nil {
+		fmt.Printf("close: could not persist mempool, keeping snapshot journal: %s\n", err)
+	} else if len(s.tree.diffs) == 0 {
+		if err := truncateJournal(s.journal); err != nil {
+			fmt.Printf("close: could not truncate snapshot journal: %s\n", err)
+		}
+	}
+
+	s.journal.Close()
+	s.store.Close()
 }
 
-func (s *State) apply(tx Tx) error {
+// apply validates tx against the current top of the snapshot tree and
+// returns the resulting balances for every account it touches, without
+// mutating s.Balances directly, plus the UTXO keys tx spent/created so they
+// can be recorded on its diffLayer.
+func (s *State) apply(tx Tx) (balances map[Account]uint, utxoSpent, utxoCreated []utxoKey, err error) {
 	if tx.IsReward() {
-		s.Balances[tx.To] += tx.Value
-		return nil
+		utxoSpent, utxoCreated, err = s.applyUTXO(tx)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		return map[Account]uint{tx.To: s.tree.balance(tx.To) + tx.Value}, utxoSpent, utxoCreated, nil
 	}
 
-	if s.Balances[tx.From] < tx.Value {
-		return fmt.Errorf("insufficient balance")
+	if s.tree.balance(tx.From) < tx.Value {
+		return nil, nil, nil, fmt.Errorf("insufficient balance")
 	}
 
-	s.Balances[tx.From] -= tx.Value
-	s.Balances[tx.To] += tx.Value
+	utxoSpent, utxoCreated, err = s.applyUTXO(tx)
+	if err != nil {
+		return nil, nil, nil, err
+	}
 
-	return nil
+	return map[Account]uint{
+		tx.From: s.tree.balance(tx.From) - tx.Value,
+		tx.To:   s.tree.balance(tx.To) + tx.Value,
+	}, utxoSpent, utxoCreated, nil
 }
 
+// computeRoot derives the root hash of a would-be diff-layer from its parent
+// root and the balance mutations (and txs) it would carry.
+func (s *State) computeRoot(parent Hash, diffs map[Account]uint, txs []Tx) Hash {
+	data, err := json.Marshal(struct {
+		Parent Hash
+		Diffs  map[Account]uint
+		Txs    []Tx
+	}{parent, diffs, txs})
+	if err != nil {
+		panic(err)
+	}
+
+	return sha256.Sum256(data)
+}
+
+// doSnapshot re-derives s.snapshot by walking every block the store knows
+// about, tracking height/tx-count/size as it goes rather than only
+// sha256-hashing the file bytes - this way it works the same whether
+// s.store is a fileStore or a levelDBStore.
 func (s *State) doSnapshot() error {
-	_, err := s.dbFile.Seek(0, 0)
+	it, err := s.store.Iterate(Hash{})
 	if err != nil {
 		return err
 	}
 
-	txsData, err := ioutil.ReadAll(s.dbFile)
-	if err != nil {
+	h := sha256.New()
+	snap := Snapshot{}
+	for it.Next() {
+		blockJSON, err := json.Marshal(it.Block())
+		if err != nil {
+			return err
+		}
+		h.Write(blockJSON)
+
+		snap.Height++
+		snap.TxCount += uint64(len(it.Block().TXs))
+		snap.SizeBytes += int64(len(blockJSON))
+	}
+	if err := it.Err(); err != nil {
 		return err
 	}
-	s.snapshot = sha256.Sum256(txsData)
 
+	copy(snap.Hash[:], h.Sum(nil))
+	s.snapshot = snap
 	return nil
 }
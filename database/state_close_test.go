@@ -0,0 +1,95 @@
+package database
+
+import "testing"
+
+func TestCloseKeepsJournalWhenPersistFails(t *testing.T) {
+	s := newTestState(t, map[Account]uint{"alice": 1000})
+
+	if err := s.Add(Tx{From: "alice", To: "bob", Value: 100}); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+
+	s.store.(*fakeStore).failPut = true
+	s.Close()
+
+	// Close closes s.journal, so read the journal back through a new handle.
+	f, err := openJournal(s.dataDir)
+	if err != nil {
+		t.Fatalf("openJournal: %v", err)
+	}
+	defer f.Close()
+
+	entries, err := readJournal(f)
+	if err != nil {
+		t.Fatalf("readJournal: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("Close discarded the journal despite a failed Persist: got %d entries, want 1", len(entries))
+	}
+}
+
+func TestCloseTruncatesJournalOnceFlushed(t *testing.T) {
+	s := newTestState(t, map[Account]uint{"alice": 1000})
+
+	if err := s.Add(Tx{From: "alice", To: "bob", Value: 100}); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+
+	s.Close()
+
+	f, err := openJournal(s.dataDir)
+	if err != nil {
+		t.Fatalf("openJournal: %v", err)
+	}
+	defer f.Close()
+
+	entries, err := readJournal(f)
+	if err != nil {
+		t.Fatalf("readJournal: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("Close left %d stale journal entries after a successful Persist", len(entries))
+	}
+}
+
+func TestReplayJournalEntriesRepopulatesMempool(t *testing.T) {
+	// Simulate a crash: a tx was appended to the journal (and pushed as a
+	// diffLayer) but never made it into txMempool of the "new" process, the
+	// way a restart would find things after readJournal.
+	crashed := newTestState(t, map[Account]uint{"alice": 1000})
+	if err := crashed.Add(Tx{From: "alice", To: "bob", Value: 100}); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	entries, err := readJournal(crashed.journal)
+	if err != nil {
+		t.Fatalf("readJournal: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("got %d journal entries, want 1", len(entries))
+	}
+
+	recovered := newTestState(t, map[Account]uint{"alice": 1000})
+	replayJournalEntries(recovered, recovered.tree, entries)
+	recovered.Balances = recovered.tree.balances()
+
+	if len(recovered.txMempool) != 1 {
+		t.Fatalf("txMempool has %d txs after replay, want 1 (recovered tx is unflushable)", len(recovered.txMempool))
+	}
+	if recovered.Balances["bob"] != 100 {
+		t.Fatalf("bob balance after replay = %d, want 100", recovered.Balances["bob"])
+	}
+
+	if _, err := recovered.Persist(); err != nil {
+		t.Fatalf("Persist after replay: %v", err)
+	}
+	if len(recovered.txMempool) != 0 {
+		t.Fatalf("txMempool not drained by Persist after replay, left with %d txs", len(recovered.txMempool))
+	}
+	fs := recovered.store.(*fakeStore)
+	if len(fs.blocks) != 1 {
+		t.Fatalf("recovered tx never reached the store: got %d blocks, want 1", len(fs.blocks))
+	}
+	if len(recovered.tree.diffs) != 0 {
+		t.Fatalf("recovered diffLayer never flattened, tree.diffs has %d layers", len(recovered.tree.diffs))
+	}
+}
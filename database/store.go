@@ -0,0 +1,45 @@
+package database
+
+// Store is the persistence layer behind State: something that can append and
+// look up blocks by hash, without requiring callers to scan the whole
+// history to find one. fileStore keeps the historic append-only block.db
+// format; levelDBStore backs the same interface with LevelDB so replay on
+// startup walks a height -> hash index instead of a bufio.Scanner pass over
+// the file.
+type Store interface {
+	// GetBlock returns the block stored under hash.
+	GetBlock(hash Hash) (Block, error)
+	// PutBlock appends block to the store and returns its hash.
+	PutBlock(block Block) (Hash, error)
+	// LatestHash returns the hash of the most recently stored block, and
+	// false if the store is empty.
+	LatestHash() (Hash, bool)
+	// Iterate walks the chain of blocks oldest first, starting just after
+	// from, or at the first stored block when from is the zero hash.
+	Iterate(from Hash) (StoreIterator, error)
+	// Close releases any file handles or connections held by the store.
+	Close() error
+}
+
+// StoreIterator walks the blocks a Store knows about one at a time.
+type StoreIterator interface {
+	Next() bool
+	Block() Block
+	Err() error
+}
+
+// StoreConfig selects and configures the Store backend NewStateFromDisk
+// should use.
+type StoreConfig struct {
+	// DataDir is the directory the store's files live in, e.g. the
+	// directory containing block.db or the LevelDB data directory.
+	DataDir string
+	// UseLevelDB switches the backend from the historic append-only
+	// block.db file to a LevelDB instance rooted at DataDir.
+	UseLevelDB bool
+	// JournalCheck makes NewStateFromDisk verify the snapshot journal
+	// (see snapshot_journal.go) before trusting it, falling back to
+	// disk-layer-only state on any inconsistency instead of replaying a
+	// possibly corrupt journal.
+	JournalCheck bool
+}
@@ -0,0 +1,204 @@
+package database
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// UTXOEntry is a single unspent transaction output, tracked alongside the
+// account-balance model so tooling can migrate to pure UTXO semantics
+// without the account map going away underneath it.
+type UTXOEntry struct {
+	TxHash Hash
+	Index  uint
+	Value  uint
+	Owner  Account
+	Spent  bool
+}
+
+type utxoKey struct {
+	txHash Hash
+	index  uint
+}
+
+// UTXOView is the set of outputs State.apply has created, spent or not,
+// minus those a State.Revert has undone. It's updated in lock-step with the balance map: every applied Tx
+// consumes the inputs it draws its value from and creates new outputs for
+// its recipient (and change for its sender).
+type UTXOView struct {
+	entries map[utxoKey]*UTXOEntry
+}
+
+func newUTXOView() *UTXOView {
+	return &UTXOView{entries: make(map[utxoKey]*UTXOEntry)}
+}
+
+// Get looks up a single output by the tx that created it and its index
+// within that tx.
+func (v *UTXOView) Get(hash Hash, index uint) (UTXOEntry, bool) {
+	e, ok := v.entries[utxoKey{hash, index}]
+	if !ok {
+		return UTXOEntry{}, false
+	}
+	return *e, true
+}
+
+// SpendableFor returns every unspent output owned by account, ordered by
+// (TxHash, Index) so coin selection in applyUTXO is deterministic - ranging
+// over the underlying map directly would pick a different set of inputs on
+// every run, which would make replaying the same block.db reconstruct a
+// UTXO set that doesn't match the original.
+func (v *UTXOView) SpendableFor(account Account) []UTXOEntry {
+	var out []UTXOEntry
+	for _, e := range v.entries {
+		if !e.Spent && e.Owner == account {
+			out = append(out, *e)
+		}
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		if cmp := bytes.Compare(out[i].TxHash[:], out[j].TxHash[:]); cmp != 0 {
+			return cmp < 0
+		}
+		return out[i].Index < out[j].Index
+	})
+
+	return out
+}
+
+func (v *UTXOView) put(e UTXOEntry) {
+	v.entries[utxoKey{e.TxHash, e.Index}] = &e
+}
+
+func (v *UTXOView) spend(hash Hash, index uint) error {
+	e, ok := v.entries[utxoKey{hash, index}]
+	if !ok {
+		return fmt.Errorf("unknown utxo %x:%d", hash, index)
+	}
+	if e.Spent {
+		return fmt.Errorf("double spend of utxo %x:%d", hash, index)
+	}
+	e.Spent = true
+	return nil
+}
+
+// undo reverses a single applyUTXO call: spent is unmarked as spent, created
+// is removed outright. Callers pass these from the diffLayer the tx that
+// produced them was recorded on, so State.Revert can keep s.utxo in sync
+// with the balance diff-layers it discards.
+func (v *UTXOView) undo(spent, created []utxoKey) {
+	for _, k := range spent {
+		if e, ok := v.entries[k]; ok {
+			e.Spent = false
+		}
+	}
+	for _, k := range created {
+		delete(v.entries, k)
+	}
+}
+
+// saveToFile writes the view out as JSON, separate from block.db, so it can
+// be inspected without replaying the whole ledger.
+func (v *UTXOView) saveToFile(path string) error {
+	entries := make([]UTXOEntry, 0, len(v.entries))
+	for _, e := range v.entries {
+		entries = append(entries, *e)
+	}
+
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, 0600)
+}
+
+// applyUTXO mirrors apply's balance-map bookkeeping in UTXO terms: rewards
+// mint a coinbase output, ordinary txs spend enough of tx.From's unspent
+// outputs to cover tx.Value, create a new output for tx.To, and return any
+// excess to tx.From as a change output. It returns the keys of every output
+// spent and created so the caller can record them on tx's diffLayer and
+// undo them later via UTXOView.undo.
+func (s *State) applyUTXO(tx Tx) (spent, created []utxoKey, err error) {
+	hash, err := hashTx(tx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if tx.IsReward() {
+		s.utxo.put(UTXOEntry{TxHash: hash, Index: 0, Value: tx.Value, Owner: tx.To})
+		return nil, []utxoKey{{hash, 0}}, nil
+	}
+
+	spendable := s.utxo.SpendableFor(tx.From)
+
+	var collected uint
+	var toSpend []UTXOEntry
+	for _, e := range spendable {
+		if collected >= tx.Value {
+			break
+		}
+		collected += e.Value
+		toSpend = append(toSpend, e)
+	}
+
+	if collected < tx.Value {
+		return nil, nil, fmt.Errorf("insufficient spendable utxos for %s: have %d, need %d", tx.From, collected, tx.Value)
+	}
+
+	for _, e := range toSpend {
+		if err := s.utxo.spend(e.TxHash, e.Index); err != nil {
+			return nil, nil, err
+		}
+		spent = append(spent, utxoKey{e.TxHash, e.Index})
+	}
+
+	s.utxo.put(UTXOEntry{TxHash: hash, Index: 0, Value: tx.Value, Owner: tx.To})
+	created = append(created, utxoKey{hash, 0})
+	if change := collected - tx.Value; change > 0 {
+		s.utxo.put(UTXOEntry{TxHash: hash, Index: 1, Value: change, Owner: tx.From})
+		created = append(created, utxoKey{hash, 1})
+	}
+
+	return spent, created, nil
+}
+
+// GetUTXO looks up a single unspent (or spent) output by the hash of the tx
+// that created it and its index within that tx.
+func (s *State) GetUTXO(hash Hash, index uint) (UTXOEntry, bool) {
+	return s.utxo.Get(hash, index)
+}
+
+// SpendableFor returns every unspent output owned by account.
+func (s *State) SpendableFor(account Account) []UTXOEntry {
+	return s.utxo.SpendableFor(account)
+}
+
+// seedGenesisUTXO seeds v with one coinbase-style output per genesis
+// account, keyed off the zero hash, so an account that has never received a
+// UTXO-creating Tx can still spend the balance it started with. Accounts are
+// assigned indexes in sorted order so replay reconstructs the same keys
+// every time.
+func seedGenesisUTXO(v *UTXOView, balances map[Account]uint) {
+	accounts := make([]Account, 0, len(balances))
+	for account := range balances {
+		accounts = append(accounts, account)
+	}
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i] < accounts[j] })
+
+	for i, account := range accounts {
+		v.put(UTXOEntry{TxHash: Hash{}, Index: uint(i), Value: balances[account], Owner: account})
+	}
+}
+
+func hashTx(tx Tx) (Hash, error) {
+	data, err := json.Marshal(tx)
+	if err != nil {
+		return Hash{}, err
+	}
+	return sha256.Sum256(data), nil
+}
@@ -0,0 +1,75 @@
+package database
+
+import "testing"
+
+func TestSeedGenesisUTXOIsDeterministic(t *testing.T) {
+	balances := map[Account]uint{
+		Account("bob"):   100,
+		Account("alice"): 200,
+		Account("carl"):  50,
+	}
+
+	var first, second []UTXOEntry
+	for i := 0; i < 5; i++ {
+		v := newUTXOView()
+		seedGenesisUTXO(v, balances)
+
+		entries := v.SpendableFor(Account("alice"))
+		entries = append(entries, v.SpendableFor(Account("bob"))...)
+		entries = append(entries, v.SpendableFor(Account("carl"))...)
+
+		if i == 0 {
+			first = entries
+		} else {
+			second = entries
+			if len(first) != len(second) {
+				t.Fatalf("run %d: got %d entries, want %d", i, len(second), len(first))
+			}
+			for j := range first {
+				if first[j] != second[j] {
+					t.Fatalf("run %d: entry %d = %+v, want %+v (non-deterministic seeding)", i, j, second[j], first[j])
+				}
+			}
+		}
+	}
+}
+
+func TestSpendableForIsSortedDeterministically(t *testing.T) {
+	v := newUTXOView()
+	v.put(UTXOEntry{TxHash: Hash{2}, Index: 0, Value: 10, Owner: "alice"})
+	v.put(UTXOEntry{TxHash: Hash{1}, Index: 1, Value: 5, Owner: "alice"})
+	v.put(UTXOEntry{TxHash: Hash{1}, Index: 0, Value: 7, Owner: "alice"})
+
+	for i := 0; i < 10; i++ {
+		got := v.SpendableFor("alice")
+		if len(got) != 3 {
+			t.Fatalf("expected 3 spendable entries, got %d", len(got))
+		}
+		if got[0].TxHash != (Hash{1}) || got[0].Index != 0 {
+			t.Fatalf("entry 0 = %+v, want TxHash=%x Index=0", got[0], Hash{1})
+		}
+		if got[1].TxHash != (Hash{1}) || got[1].Index != 1 {
+			t.Fatalf("entry 1 = %+v, want TxHash=%x Index=1", got[1], Hash{1})
+		}
+		if got[2].TxHash != (Hash{2}) || got[2].Index != 0 {
+			t.Fatalf("entry 2 = %+v, want TxHash=%x Index=0", got[2], Hash{2})
+		}
+	}
+}
+
+func TestSpendMarksSpentAndRejectsDoubleSpend(t *testing.T) {
+	v := newUTXOView()
+	v.put(UTXOEntry{TxHash: Hash{1}, Index: 0, Value: 10, Owner: "alice"})
+
+	if err := v.spend(Hash{1}, 0); err != nil {
+		t.Fatalf("first spend: unexpected error: %v", err)
+	}
+
+	if err := v.spend(Hash{1}, 0); err == nil {
+		t.Fatal("expected double-spend error, got nil")
+	}
+
+	if got := v.SpendableFor("alice"); len(got) != 0 {
+		t.Fatalf("expected no spendable entries after spend, got %+v", got)
+	}
+}